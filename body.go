@@ -0,0 +1,157 @@
+// body.go
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// defaultRewriteBodyMaxBytes bounds how much of a response body is buffered
+// for rewriting before falling back to passthrough.
+const defaultRewriteBodyMaxBytes = 2 << 20 // 2 MiB
+
+// matchesContentType reports whether contentType (as found on a Content-Type
+// header, parameters and all) matches any of the glob patterns in globs.
+func matchesContentType(contentType string, globs []string) bool {
+	if len(globs) == 0 || contentType == "" {
+		return false
+	}
+
+	base, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		base, _, _ = strings.Cut(contentType, ";")
+		base = strings.TrimSpace(base)
+	}
+
+	for _, glob := range globs {
+		if matched, err := path.Match(glob, base); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteBody replaces config.SourceCookieName with config.TargetCookieName
+// in body, honoring the Content-Encoding header (gzip/deflate are decoded
+// before the substitution and re-encoded after). It returns ok=false,
+// unchanged body whenever the content type doesn't match, the encoding isn't
+// one decodeBody/encodeBody can round-trip, or nothing was actually
+// replaced, so callers can skip touching Content-Length. bodyRewriteRegex is
+// the pattern New() compiled from config.RewriteBodyPattern, used when
+// RewriteBodyStrategy is "regex"; it's nil otherwise.
+func rewriteBody(body []byte, header http.Header, config *Config, bodyRewriteRegex *regexp.Regexp) ([]byte, bool) {
+	if !matchesContentType(header.Get("Content-Type"), config.RewriteBodyContentTypes) {
+		return body, false
+	}
+
+	encoding := header.Get("Content-Encoding")
+	decoded, err := decodeBody(body, encoding)
+	if err != nil {
+		return body, false
+	}
+
+	rewritten := applyBodyReplacement(decoded, config, bodyRewriteRegex)
+	if bytes.Equal(rewritten, decoded) {
+		return body, false
+	}
+
+	encoded, err := encodeBody(rewritten, encoding)
+	if err != nil {
+		return body, false
+	}
+	return encoded, true
+}
+
+// applyBodyReplacement runs the configured RewriteBodyStrategy over data.
+func applyBodyReplacement(data []byte, config *Config, bodyRewriteRegex *regexp.Regexp) []byte {
+	text := string(data)
+
+	switch config.RewriteBodyStrategy {
+	case "jsonEscaped":
+		text = strings.ReplaceAll(text, jsonEscape(config.SourceCookieName), jsonEscape(config.TargetCookieName))
+	case "regex":
+		if bodyRewriteRegex != nil {
+			text = bodyRewriteRegex.ReplaceAllString(text, config.TargetCookieName)
+		}
+	default: // "literal"
+		text = strings.ReplaceAll(text, config.SourceCookieName, config.TargetCookieName)
+	}
+
+	return []byte(text)
+}
+
+// jsonEscape returns s as it would appear inside a JSON string literal,
+// without the surrounding quotes, so callers can find/replace it in
+// already-quoted JSON or JS source text.
+func jsonEscape(s string) string {
+	encoded, _ := json.Marshal(s)
+	return strings.Trim(string(encoded), `"`)
+}
+
+// decodeBody undoes Content-Encoding so the body can be matched/replaced as
+// text. Encodings it doesn't know how to re-encode (e.g. "br", "zstd") are
+// reported as an error rather than passed through as if they were identity:
+// otherwise the raw compressed bytes would get run through
+// applyBodyReplacement as text, and a false-positive match would come back
+// out of encodeBody uncompressed under a Content-Encoding header that still
+// says otherwise.
+func decodeBody(body []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", "identity":
+		return body, nil
+	case "gzip":
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case "deflate":
+		r := flate.NewReader(bytes.NewReader(body))
+		defer r.Close()
+		return io.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("body: unsupported Content-Encoding %q", encoding)
+	}
+}
+
+func encodeBody(body []byte, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	switch encoding {
+	case "", "identity":
+		return body, nil
+	case "gzip":
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(body); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	case "deflate":
+		w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(body); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("body: unsupported Content-Encoding %q", encoding)
+	}
+
+	return buf.Bytes(), nil
+}
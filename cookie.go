@@ -0,0 +1,139 @@
+// cookie.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseSetCookie parses a single Set-Cookie header value into an
+// *http.Cookie. It's modeled on net/http's own Set-Cookie parsing (the
+// unexported readSetCookies, and the 1.23+ http.ParseSetCookie built on top
+// of it) rather than calling http.ParseSetCookie directly: Traefik plugins
+// run inside a Yaegi interpreter whose stdlib bindings are generated per Go
+// version, and ParseSetCookie is only available from Go 1.23 onward, which
+// would make the plugin fail to load on older toolchains.
+func parseSetCookie(line string) (*http.Cookie, error) {
+	parts := strings.Split(strings.TrimSpace(line), ";")
+	if len(parts) == 1 && parts[0] == "" {
+		return nil, errors.New("cookie: empty Set-Cookie header")
+	}
+
+	nameValue := strings.TrimSpace(parts[0])
+	eq := strings.Index(nameValue, "=")
+	if eq < 0 {
+		return nil, fmt.Errorf("cookie: invalid Set-Cookie name=value pair %q", nameValue)
+	}
+
+	name := nameValue[:eq]
+	if !isValidCookieToken(name) {
+		return nil, fmt.Errorf("cookie: invalid cookie name %q", name)
+	}
+
+	value, ok := unquoteCookieValue(nameValue[eq+1:])
+	if !ok {
+		return nil, fmt.Errorf("cookie: invalid cookie value for %q", name)
+	}
+
+	c := &http.Cookie{Name: name, Value: value, Raw: line}
+
+	for _, attr := range parts[1:] {
+		attr = strings.TrimSpace(attr)
+		if attr == "" {
+			continue
+		}
+
+		attrName, attrValue := attr, ""
+		if eq := strings.Index(attr, "="); eq >= 0 {
+			attrName, attrValue = attr[:eq], strings.TrimSpace(attr[eq+1:])
+		}
+
+		switch strings.ToLower(attrName) {
+		case "secure":
+			c.Secure = true
+		case "httponly":
+			c.HttpOnly = true
+		case "domain":
+			c.Domain = strings.TrimPrefix(attrValue, ".")
+		case "path":
+			c.Path = attrValue
+		case "max-age":
+			if maxAge, err := strconv.Atoi(attrValue); err == nil {
+				// As in net/http, "Max-Age=0" or below means delete now,
+				// which http.Cookie spells as a negative MaxAge.
+				if maxAge <= 0 {
+					maxAge = -1
+				}
+				c.MaxAge = maxAge
+			}
+		case "expires":
+			c.Expires = parseCookieExpires(attrValue)
+		case "samesite":
+			c.SameSite = sameSiteFromString(attrValue)
+		}
+	}
+
+	return c, nil
+}
+
+// parseCookieExpires parses the Expires attribute, trying the formats
+// actually seen in the wild: RFC 1123 (the format http.Cookie.String emits)
+// and the legacy dash-separated form some servers still send
+// (e.g. "Wed, 09-Jun-2021 10:18:14 GMT").
+func parseCookieExpires(value string) time.Time {
+	for _, layout := range []string{time.RFC1123, "Mon, 02-Jan-2006 15:04:05 MST"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
+
+// isValidCookieToken reports whether s is a valid RFC 2616 token, which
+// RFC 6265 uses for the cookie-name production.
+func isValidCookieToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isTokenByte(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTokenByte(b byte) bool {
+	if b <= 0x20 || b >= 0x7f {
+		return false
+	}
+	switch b {
+	case '(', ')', '<', '>', '@', ',', ';', ':', '\\', '"', '/', '[', ']', '?', '=', '{', '}':
+		return false
+	}
+	return true
+}
+
+// unquoteCookieValue strips an optional pair of surrounding DQUOTEs (RFC
+// 6265 allows a cookie-value to be wrapped in them) and validates every
+// remaining byte is a legal cookie-octet.
+func unquoteCookieValue(v string) (string, bool) {
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		v = v[1 : len(v)-1]
+	}
+	for i := 0; i < len(v); i++ {
+		if !isCookieOctet(v[i]) {
+			return "", false
+		}
+	}
+	return v, true
+}
+
+func isCookieOctet(b byte) bool {
+	return b == 0x21 || (b >= 0x23 && b <= 0x2b) || (b >= 0x2d && b <= 0x3a) ||
+		(b >= 0x3c && b <= 0x5b) || (b >= 0x5d && b <= 0x7e)
+}
@@ -0,0 +1,101 @@
+// domain.go
+package main
+
+import (
+	"net"
+	"strings"
+)
+
+// twoLabelPublicSuffixes holds the public suffixes this plugin recognizes
+// that span two labels (e.g. "co.uk"), so DomainStrategy "registrable" can
+// compute the correct eTLD+1 for them instead of just taking the last two
+// labels. It isn't the full Public Suffix List - pulling in
+// golang.org/x/net/publicsuffix isn't an option since Traefik plugins run
+// in a Yaegi sandbox restricted to the standard library - but it covers the
+// multi-label suffixes operators are most likely to actually see.
+var twoLabelPublicSuffixes = map[string]bool{
+	"co.uk":  true,
+	"org.uk": true,
+	"me.uk":  true,
+	"ac.uk":  true,
+	"gov.uk": true,
+	"co.jp":  true,
+	"ne.jp":  true,
+	"or.jp":  true,
+	"co.in":  true,
+	"co.nz":  true,
+	"co.za":  true,
+	"com.au": true,
+	"net.au": true,
+	"org.au": true,
+	"com.br": true,
+	"com.cn": true,
+	"com.mx": true,
+	"com.sg": true,
+}
+
+// stripPort removes a ":port" suffix from host, if present. Host headers
+// for IPv6 literals arrive as "[::1]:8080", which net.SplitHostPort also
+// handles.
+func stripPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// isLocalhostHost reports whether host is "localhost" or a "*.localhost"
+// subdomain, per RFC 6265's treatment of hosts without a public suffix.
+func isLocalhostHost(host string) bool {
+	return host == "localhost" || strings.HasSuffix(host, ".localhost")
+}
+
+// registrableDomain reduces host to its eTLD+1 (e.g. "app.example.co.uk"
+// -> "example.co.uk"), using twoLabelPublicSuffixes, plus any operator-
+// supplied entries in extra (from Config.PublicSuffixes), to recognize
+// multi-label suffixes and otherwise assuming a single-label TLD.
+func registrableDomain(host string, extra map[string]bool) string {
+	labels := strings.Split(host, ".")
+	if len(labels) <= 2 {
+		return host
+	}
+
+	lastTwo := labels[len(labels)-2] + "." + labels[len(labels)-1]
+	if twoLabelPublicSuffixes[lastTwo] || extra[lastTwo] {
+		if len(labels) < 3 {
+			return host
+		}
+		return strings.Join(labels[len(labels)-3:], ".")
+	}
+
+	return strings.Join(labels[len(labels)-2:], ".")
+}
+
+// parentDomain strips one label off the left of host (e.g. "app.example.com"
+// -> "example.com"), leaving host unchanged if it's already a single label.
+func parentDomain(host string) string {
+	labels := strings.Split(host, ".")
+	if len(labels) <= 1 {
+		return host
+	}
+	return strings.Join(labels[1:], ".")
+}
+
+// isPublicSuffix reports whether domain is itself a public suffix - a bare
+// single-label TLD (e.g. "com"), or a two-label suffix recognized via
+// twoLabelPublicSuffixes or extra (e.g. "co.uk") - rather than a real
+// registrable domain. registrableDomain and parentDomain can both reduce an
+// apex host down to exactly this, and a Domain attribute equal to a public
+// suffix is rejected by browsers, so callers use this to fall back to
+// omitting the attribute instead.
+func isPublicSuffix(domain string, extra map[string]bool) bool {
+	labels := strings.Split(domain, ".")
+	switch len(labels) {
+	case 1:
+		return true
+	case 2:
+		return twoLabelPublicSuffixes[domain] || extra[domain]
+	default:
+		return false
+	}
+}
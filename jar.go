@@ -0,0 +1,226 @@
+// jar.go
+package main
+
+import (
+	"container/list"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// SessionJar is an in-memory, http.CookieJar-shaped cookie store keyed not
+// by the request's real host but by an opaque session identifier (an
+// inbound header or cookie value). It lets the plugin bridge cases where
+// the upstream expects cookies the browser client can't send back (e.g.
+// third-party context, SameSite=None restrictions): Set-Cookie headers are
+// captured here on the response path and replayed on the client's next
+// request for the same session. Callers pass a synthetic *url.URL whose
+// Host holds the session ID in place of a real domain.
+type SessionJar struct {
+	mu         sync.Mutex
+	maxEntries int
+	sessions   map[string]*sessionEntry
+	lru        *list.List // session IDs, most-recently-used at the back
+	lruElem    map[string]*list.Element
+}
+
+type sessionEntry struct {
+	cookies map[string]storedCookie // keyed by jarKey(cookie)
+}
+
+type storedCookie struct {
+	cookie  *http.Cookie
+	expires time.Time // zero means a session cookie: kept until LRU eviction
+}
+
+// NewSessionJar creates a jar that keeps at most maxEntries sessions,
+// evicting the least-recently-used one once the cap is reached. A
+// non-positive maxEntries disables the cap.
+func NewSessionJar(maxEntries int) *SessionJar {
+	return &SessionJar{
+		maxEntries: maxEntries,
+		sessions:   make(map[string]*sessionEntry),
+		lru:        list.New(),
+		lruElem:    make(map[string]*list.Element),
+	}
+}
+
+// SetCookies implements http.CookieJar, storing cookies under the session
+// ID carried in u.Host.
+func (j *SessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	sessionID := u.Host
+	if sessionID == "" || len(cookies) == 0 {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry := j.sessions[sessionID]
+	if entry == nil {
+		entry = &sessionEntry{cookies: make(map[string]storedCookie)}
+		j.sessions[sessionID] = entry
+	}
+
+	for _, cookie := range cookies {
+		key := jarKey(cookie)
+		if cookieRemoved(cookie) {
+			delete(entry.cookies, key)
+			continue
+		}
+		entry.cookies[key] = storedCookie{cookie: cookie, expires: cookieExpiry(cookie)}
+	}
+
+	j.touch(sessionID)
+	j.evictIfNeeded()
+}
+
+// Cookies implements http.CookieJar, returning the live (non-expired)
+// cookies stored for the session ID carried in u.Host.
+func (j *SessionJar) Cookies(u *url.URL) []*http.Cookie {
+	sessionID := u.Host
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry := j.sessions[sessionID]
+	if entry == nil {
+		return nil
+	}
+
+	now := time.Now()
+	var cookies []*http.Cookie
+	for key, sc := range entry.cookies {
+		if !sc.expires.IsZero() && now.After(sc.expires) {
+			delete(entry.cookies, key)
+			continue
+		}
+		cookies = append(cookies, sc.cookie)
+	}
+
+	if len(entry.cookies) == 0 {
+		delete(j.sessions, sessionID)
+		j.removeLRU(sessionID)
+		return nil
+	}
+
+	j.touch(sessionID)
+	return cookies
+}
+
+func (j *SessionJar) touch(sessionID string) {
+	if elem, ok := j.lruElem[sessionID]; ok {
+		j.lru.MoveToBack(elem)
+		return
+	}
+	j.lruElem[sessionID] = j.lru.PushBack(sessionID)
+}
+
+func (j *SessionJar) removeLRU(sessionID string) {
+	if elem, ok := j.lruElem[sessionID]; ok {
+		j.lru.Remove(elem)
+		delete(j.lruElem, sessionID)
+	}
+}
+
+func (j *SessionJar) evictIfNeeded() {
+	if j.maxEntries <= 0 {
+		return
+	}
+	for len(j.sessions) > j.maxEntries {
+		oldest := j.lru.Front()
+		if oldest == nil {
+			return
+		}
+		sessionID := oldest.Value.(string)
+		j.lru.Remove(oldest)
+		delete(j.lruElem, sessionID)
+		delete(j.sessions, sessionID)
+	}
+}
+
+// jarKey mirrors net/http/cookiejar's domain;path;name entry id, minus the
+// domain component since that's already the sessions map key.
+func jarKey(cookie *http.Cookie) string {
+	path := cookie.Path
+	if path == "" {
+		path = "/"
+	}
+	return path + ";" + cookie.Name
+}
+
+// cookieExpiry derives an absolute expiry from a cookie's MaxAge/Expires
+// attributes, in that precedence order per RFC 6265. A zero result means
+// the cookie has no expiry of its own.
+func cookieExpiry(cookie *http.Cookie) time.Time {
+	if cookie.MaxAge > 0 {
+		return time.Now().Add(time.Duration(cookie.MaxAge) * time.Second)
+	}
+	if !cookie.Expires.IsZero() {
+		return cookie.Expires
+	}
+	return time.Time{}
+}
+
+// cookieRemoved reports whether cookie is a deletion marker: MaxAge<0 or an
+// Expires timestamp already in the past.
+func cookieRemoved(cookie *http.Cookie) bool {
+	if cookie.MaxAge < 0 {
+		return true
+	}
+	return !cookie.Expires.IsZero() && cookie.Expires.Before(time.Now())
+}
+
+// sessionIDFor extracts the session identifier from req, preferring
+// SessionIDHeader over SessionIDCookieName when both are configured.
+func sessionIDFor(req *http.Request, config *Config) (string, bool) {
+	if config.SessionIDHeader != "" {
+		if v := req.Header.Get(config.SessionIDHeader); v != "" {
+			return v, true
+		}
+	}
+	if config.SessionIDCookieName != "" {
+		if c, err := req.Cookie(config.SessionIDCookieName); err == nil && c.Value != "" {
+			return c.Value, true
+		}
+	}
+	return "", false
+}
+
+// injectJarCookies adds any jar-stored cookies for sessionID that aren't
+// already present on req, so the upstream sees cookies the real client
+// couldn't carry.
+func (cm *CookieModifier) injectJarCookies(req *http.Request, sessionID string) {
+	stored := cm.jar.Cookies(&url.URL{Host: sessionID})
+	if len(stored) == 0 {
+		return
+	}
+
+	present := make(map[string]bool, len(req.Cookies()))
+	for _, c := range req.Cookies() {
+		present[c.Name] = true
+	}
+
+	for _, cookie := range stored {
+		if present[cookie.Name] {
+			continue
+		}
+		req.AddCookie(cookie)
+	}
+}
+
+// captureJarCookies stores the response's Set-Cookie headers in the jar
+// under rw.sessionID.
+func (rw *responseWriter) captureJarCookies() {
+	var cookies []*http.Cookie
+	for _, header := range rw.Header().Values("Set-Cookie") {
+		if cookie, err := parseSetCookie(header); err == nil {
+			cookies = append(cookies, cookie)
+		}
+	}
+	if len(cookies) == 0 {
+		return
+	}
+	rw.jar.SetCookies(&url.URL{Host: rw.sessionID}, cookies)
+}
@@ -2,10 +2,15 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Config holds the plugin configuration
@@ -18,47 +23,222 @@ type Config struct {
 	SameSite         string `json:"sameSite,omitempty"`
 	Path             string `json:"path,omitempty"`
 	Debug            bool   `json:"debug,omitempty"`
+
+	// DomainStrategy controls how the dynamic Domain attribute is derived
+	// from the request Host when UseDynamicDomain is set: "host" (default)
+	// uses the Host header minus any port, "registrable" strips it to the
+	// eTLD+1 (e.g. "app.example.co.uk" -> "example.co.uk"), and "parent"
+	// strips one label (e.g. "app.example.com" -> "example.com"). The
+	// leading dot these two add internally is dropped by
+	// http.Cookie.String on serialization (RFC 6265 ignores it), so every
+	// strategy emits a plain Domain=<domain> attribute. IPs and localhost
+	// never get a Domain attribute, per RFC 6265.
+	//
+	// "registrable" isn't backed by a real Public Suffix List - Traefik
+	// plugins run in a Yaegi sandbox restricted to the standard library,
+	// so golang.org/x/net/publicsuffix isn't an option - only a baked-in
+	// table of common two-label suffixes (see twoLabelPublicSuffixes in
+	// domain.go). Any multi-label public suffix not in that table (e.g.
+	// "github.io", "com.ua") resolves one label too broad. Set
+	// PublicSuffixes to extend the table for suffixes your deployment
+	// actually sees.
+	DomainStrategy string `json:"domainStrategy,omitempty"`
+
+	// PublicSuffixes lists additional two-label public suffixes (e.g.
+	// "github.io", "com.ua") for DomainStrategy "registrable" to treat the
+	// same way as the baked-in table in domain.go, so operators aren't
+	// stuck with only the suffixes this plugin ships with.
+	PublicSuffixes []string `json:"publicSuffixes,omitempty"`
+
+	// Rules, when set, replaces the single source->target rename above
+	// with an ordered pipeline of matchers and actions. See RuleConfig.
+	Rules []RuleConfig `json:"rules,omitempty"`
+
+	// SignedMode, when true, emits a paired "<targetCookieName>.sig"
+	// cookie (an HMAC-SHA256 of the target cookie's value under
+	// SigningSecret) and verifies that pairing on the way back in.
+	SignedMode bool `json:"signedMode,omitempty"`
+	// SigningSecret is required when SignedMode is enabled.
+	SigningSecret string `json:"signingSecret,omitempty"`
+	// SignatureFailAction controls what happens when a request's signed
+	// cookie fails verification: "strip" (default) drops the cookie pair
+	// and forwards the request anyway, "reject" responds 401 instead.
+	SignatureFailAction string `json:"signatureFailAction,omitempty"`
+
+	// CookieJarEnabled turns on the session-keyed cookie jar: Set-Cookie
+	// headers from the upstream are captured and replayed onto later
+	// requests that carry the same session identifier, one of
+	// SessionIDHeader or SessionIDCookieName (at least one is required).
+	CookieJarEnabled    bool   `json:"cookieJarEnabled,omitempty"`
+	SessionIDHeader     string `json:"sessionIDHeader,omitempty"`
+	SessionIDCookieName string `json:"sessionIDCookieName,omitempty"`
+	// CookieJarMaxEntries caps how many sessions the jar holds at once,
+	// evicting the least-recently-used session past that. Non-positive
+	// disables the cap.
+	CookieJarMaxEntries int `json:"cookieJarMaxEntries,omitempty"`
+
+	// RewriteBody, when true, replaces SourceCookieName with
+	// TargetCookieName inside response bodies whose Content-Type matches
+	// one of RewriteBodyContentTypes (glob patterns, e.g.
+	// "application/json", "text/*"). RewriteBodyStrategy picks how the
+	// substitution is made: "literal" (default), "jsonEscaped" (match the
+	// name as it would be JSON-string-escaped), or "regex" (match
+	// RewriteBodyPattern instead of SourceCookieName, replacing with
+	// TargetCookieName).
+	RewriteBody             bool     `json:"rewriteBody,omitempty"`
+	RewriteBodyContentTypes []string `json:"rewriteBodyContentTypes,omitempty"`
+	RewriteBodyStrategy     string   `json:"rewriteBodyStrategy,omitempty"`
+	RewriteBodyPattern      string   `json:"rewriteBodyPattern,omitempty"`
+	// RewriteBodyMaxBytes caps how much of a response body is buffered for
+	// rewriting; responses larger than this pass through unmodified.
+	RewriteBodyMaxBytes int64 `json:"rewriteBodyMaxBytes,omitempty"`
+
+	// MetricsAddr, when set, starts a standalone HTTP server on this
+	// address exposing Prometheus-format counters and a latency histogram
+	// on /metrics for the lifetime of the process.
+	MetricsAddr string `json:"metricsAddr,omitempty"`
 }
 
 // CreateConfig creates the default plugin configuration
 func CreateConfig() *Config {
 	return &Config{
-		SourceCookieName: "flowise_token",
-		TargetCookieName: "simple_token",
-		UseDynamicDomain: true,
-		Secure:           false,
-		HttpOnly:         false,
-		SameSite:         "Lax",
-		Path:             "/",
-		Debug:            false,
+		SourceCookieName:    "flowise_token",
+		TargetCookieName:    "simple_token",
+		UseDynamicDomain:    true,
+		DomainStrategy:      "host",
+		Secure:              false,
+		HttpOnly:            false,
+		SameSite:            "Lax",
+		Path:                "/",
+		Debug:               false,
+		SignatureFailAction: "strip",
+		CookieJarMaxEntries: 10000,
 	}
 }
 
 // CookieModifier holds the plugin instance
 type CookieModifier struct {
-	next   http.Handler
-	config *Config
-	name   string
+	next             http.Handler
+	config           *Config
+	name             string
+	rules            []compiledRule
+	jar              *SessionJar
+	metrics          *Metrics
+	publicSuffixes   map[string]bool
+	bodyRewriteRegex *regexp.Regexp
 }
 
 // New creates a new plugin instance
 func New(ctx context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
 	// Validate configuration
-	if config.SourceCookieName == "" {
-		return nil, fmt.Errorf("sourceCookieName cannot be empty")
+	if len(config.Rules) == 0 {
+		if config.SourceCookieName == "" {
+			return nil, fmt.Errorf("sourceCookieName cannot be empty")
+		}
+		if config.TargetCookieName == "" {
+			return nil, fmt.Errorf("targetCookieName cannot be empty")
+		}
+	}
+
+	rules, err := compileRules(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+
+	switch config.DomainStrategy {
+	case "", "host":
+		config.DomainStrategy = "host"
+	case "registrable", "parent":
+	default:
+		return nil, fmt.Errorf("domainStrategy must be \"host\", \"registrable\" or \"parent\", got %q", config.DomainStrategy)
+	}
+
+	var publicSuffixes map[string]bool
+	if len(config.PublicSuffixes) > 0 {
+		publicSuffixes = make(map[string]bool, len(config.PublicSuffixes))
+		for _, suffix := range config.PublicSuffixes {
+			publicSuffixes[suffix] = true
+		}
+	}
+
+	if config.SignedMode {
+		if config.SigningSecret == "" {
+			return nil, fmt.Errorf("signingSecret cannot be empty when signedMode is enabled")
+		}
+		// Signing only ever looks at TargetCookieName (see signing.go): it
+		// predates the rule pipeline and hasn't been taught to pull a
+		// target from Rules. Require it explicitly here rather than let an
+		// operator who's migrated to Rules end up with SignedMode that
+		// silently never signs or verifies anything.
+		if len(config.Rules) > 0 && config.TargetCookieName == "" {
+			return nil, fmt.Errorf("targetCookieName must be set when signedMode is enabled, even with rules configured")
+		}
+		switch config.SignatureFailAction {
+		case "", "strip":
+			config.SignatureFailAction = "strip"
+		case "reject":
+		default:
+			return nil, fmt.Errorf("signatureFailAction must be \"strip\" or \"reject\", got %q", config.SignatureFailAction)
+		}
+	}
+
+	if config.CookieJarEnabled && config.SessionIDHeader == "" && config.SessionIDCookieName == "" {
+		return nil, fmt.Errorf("sessionIDHeader or sessionIDCookieName must be set when cookieJarEnabled is true")
 	}
-	if config.TargetCookieName == "" {
-		return nil, fmt.Errorf("targetCookieName cannot be empty")
+
+	var bodyRewriteRegex *regexp.Regexp
+	if config.RewriteBody {
+		if len(config.RewriteBodyContentTypes) == 0 {
+			return nil, fmt.Errorf("rewriteBodyContentTypes cannot be empty when rewriteBody is enabled")
+		}
+		switch config.RewriteBodyStrategy {
+		case "", "literal":
+			config.RewriteBodyStrategy = "literal"
+		case "jsonEscaped", "regex":
+		default:
+			return nil, fmt.Errorf("rewriteBodyStrategy must be \"literal\", \"jsonEscaped\" or \"regex\", got %q", config.RewriteBodyStrategy)
+		}
+		if config.RewriteBodyStrategy == "regex" {
+			if config.RewriteBodyPattern == "" {
+				return nil, fmt.Errorf("rewriteBodyPattern is required when rewriteBodyStrategy is \"regex\"")
+			}
+			re, err := regexp.Compile(config.RewriteBodyPattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rewriteBodyPattern: %w", err)
+			}
+			bodyRewriteRegex = re
+		}
+		if config.RewriteBodyMaxBytes <= 0 {
+			config.RewriteBodyMaxBytes = defaultRewriteBodyMaxBytes
+		}
 	}
 
 	if config.Debug {
 		fmt.Printf("[Cookie Modifier] Plugin initialized with config: %+v\n", config)
 	}
 
+	var jar *SessionJar
+	if config.CookieJarEnabled {
+		jar = NewSessionJar(config.CookieJarMaxEntries)
+	}
+
+	var metrics *Metrics
+	if config.MetricsAddr != "" {
+		metrics, err = acquireMetrics(config.MetricsAddr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &CookieModifier{
-		next:   next,
-		config: config,
-		name:   name,
+		next:             next,
+		config:           config,
+		name:             name,
+		rules:            rules,
+		jar:              jar,
+		metrics:          metrics,
+		publicSuffixes:   publicSuffixes,
+		bodyRewriteRegex: bodyRewriteRegex,
 	}, nil
 }
 
@@ -68,141 +248,283 @@ func (cm *CookieModifier) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		fmt.Printf("[Cookie Modifier] Processing request to: %s\n", req.URL.String())
 	}
 
+	if cm.metrics != nil {
+		cm.metrics.IncRequests()
+	}
+
 	// Transform request cookies
 	cm.transformRequestCookies(req)
 
+	if cm.config.SignedMode && !cm.verifyRequestSignature(req) {
+		if cm.metrics != nil {
+			cm.metrics.IncSignatureFailure()
+		}
+		if cm.config.SignatureFailAction == "reject" {
+			http.Error(rw, "invalid cookie signature", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var sessionID string
+	if cm.jar != nil {
+		if id, ok := sessionIDFor(req, cm.config); ok {
+			sessionID = id
+			cm.injectJarCookies(req, sessionID)
+		}
+	}
+
 	// Create a custom response writer to intercept response cookies
 	wrappedWriter := &responseWriter{
-		ResponseWriter: rw,
-		req:            req,
-		config:         cm.config,
+		ResponseWriter:   rw,
+		req:              req,
+		config:           cm.config,
+		rules:            cm.rules,
+		jar:              cm.jar,
+		sessionID:        sessionID,
+		metrics:          cm.metrics,
+		publicSuffixes:   cm.publicSuffixes,
+		bodyRewriteRegex: cm.bodyRewriteRegex,
 	}
 
 	// Continue to the next handler
 	cm.next.ServeHTTP(wrappedWriter, req)
+
+	// Flush any body buffered for rewriting; a no-op when RewriteBody
+	// never kicked in for this response.
+	wrappedWriter.finalize()
 }
 
-// transformRequestCookies modifies cookies in the incoming request
+// transformRequestCookies runs every cookie in the incoming request through
+// the rule pipeline and rewrites the Cookie header with the result. Cookies
+// that no rule matches pass through untouched; the Cookie header format
+// carries no attributes, so only name/value changes (rename, value rewrite,
+// drop, split) are observable here.
 func (cm *CookieModifier) transformRequestCookies(req *http.Request) {
 	cookies := req.Cookies()
-	var newCookies []*http.Cookie
-	var foundSourceCookie *http.Cookie
+	if len(cookies) == 0 {
+		return
+	}
+
+	if cm.metrics != nil {
+		defer func(start time.Time) { cm.metrics.ObserveLatency(time.Since(start)) }(time.Now())
+	}
 
 	if cm.config.Debug {
 		fmt.Printf("[Cookie Modifier] Found %d cookies in request\n", len(cookies))
 	}
 
-	// Find the source cookie and collect other cookies
+	domain := dynamicDomain(req, cm.config, cm.publicSuffixes)
+
+	var observe matchObserver
+	if cm.metrics != nil {
+		observe = func(rule string) { cm.metrics.IncTransform("request", rule) }
+	}
+
+	var newCookies []*http.Cookie
 	for _, cookie := range cookies {
-		if cookie.Name == cm.config.SourceCookieName {
-			foundSourceCookie = cookie
-			if cm.config.Debug {
-				fmt.Printf("[Cookie Modifier] Found source cookie: %s=%s\n", cookie.Name, cookie.Value)
-			}
-		} else {
-			newCookies = append(newCookies, cookie)
-		}
+		newCookies = append(newCookies, runPipeline(cookie, cm.rules, domain, observe)...)
 	}
 
-	// If source cookie found, create the transformed cookie
-	if foundSourceCookie != nil {
-		transformedCookie := &http.Cookie{
-			Name:  cm.config.TargetCookieName,
-			Value: foundSourceCookie.Value,
-			Path:  cm.config.Path,
-		}
+	// The Cookie header only ever carries name=value pairs, never
+	// attributes, so rebuild it from bare Name/Value copies. Routing
+	// through http.Cookie.String() (rather than fmt.Sprintf) gets us
+	// correct quoting for values it wouldn't otherwise be valid to send.
+	var cookieStrings []string
+	for _, cookie := range newCookies {
+		nameValue := &http.Cookie{Name: cookie.Name, Value: cookie.Value}
+		cookieStrings = append(cookieStrings, nameValue.String())
+	}
+	req.Header.Set("Cookie", strings.Join(cookieStrings, "; "))
 
-		// Set domain to the target URL if dynamic domain is enabled
-		if cm.config.UseDynamicDomain {
-			// Use the Host header to determine the domain
-			if req.Host != "" {
-				transformedCookie.Domain = req.Host
-				if cm.config.Debug {
-					fmt.Printf("[Cookie Modifier] Set dynamic domain to: %s\n", req.Host)
-				}
-			}
-		}
+	if cm.config.Debug {
+		fmt.Printf("[Cookie Modifier] Request cookies after pipeline: %s\n", req.Header.Get("Cookie"))
+	}
+}
 
-		newCookies = append(newCookies, transformedCookie)
+// dynamicDomain returns the domain to use wherever a rule asks for the
+// legacy, UseDynamicDomain-driven domain instead of a literal one. It
+// returns "" (meaning: omit the Domain attribute) for IP addresses and
+// localhost, which per RFC 6265 can't carry a Domain attribute, and also
+// whenever "registrable" or "parent" would otherwise reduce host to a bare
+// public suffix (e.g. "com", or "co.uk" itself): a Domain attribute equal
+// to a public suffix is exactly what browsers reject cookies for, so
+// there's no usable Domain to emit and host-only scoping is the closest
+// fallback. publicSuffixes extends the baked-in table DomainStrategy
+// "registrable" consults, from config.PublicSuffixes.
+func dynamicDomain(req *http.Request, config *Config, publicSuffixes map[string]bool) string {
+	if !config.UseDynamicDomain || req.Host == "" {
+		return ""
+	}
 
-		// Rebuild the Cookie header
-		var cookieStrings []string
-		for _, cookie := range newCookies {
-			cookieStrings = append(cookieStrings, fmt.Sprintf("%s=%s", cookie.Name, cookie.Value))
-		}
-		req.Header.Set("Cookie", strings.Join(cookieStrings, "; "))
+	host := stripPort(req.Host)
+	if host == "" || net.ParseIP(host) != nil || isLocalhostHost(host) {
+		return ""
+	}
 
-		if cm.config.Debug {
-			fmt.Printf("[Cookie Modifier] Transformed cookie: %s -> %s\n", 
-				cm.config.SourceCookieName, cm.config.TargetCookieName)
+	switch config.DomainStrategy {
+	case "registrable":
+		domain := registrableDomain(host, publicSuffixes)
+		if isPublicSuffix(domain, publicSuffixes) {
+			return ""
 		}
+		return "." + domain
+	case "parent":
+		domain := parentDomain(host)
+		if isPublicSuffix(domain, publicSuffixes) {
+			return ""
+		}
+		return "." + domain
+	default:
+		return host
 	}
 }
 
 // responseWriter wraps http.ResponseWriter to intercept Set-Cookie headers
 type responseWriter struct {
 	http.ResponseWriter
-	req    *http.Request
-	config *Config
+	req              *http.Request
+	config           *Config
+	rules            []compiledRule
+	jar              *SessionJar
+	sessionID        string
+	metrics          *Metrics
+	publicSuffixes   map[string]bool
+	bodyRewriteRegex *regexp.Regexp
+
+	headerWritten bool
+	statusCode    int
+	bodyBuf       *bytes.Buffer // non-nil while a body is buffered for rewriting
 }
 
-// WriteHeader intercepts response headers to modify Set-Cookie
+// WriteHeader intercepts response headers to modify Set-Cookie. If
+// RewriteBody applies to this response, the actual WriteHeader call to the
+// underlying ResponseWriter is deferred to finalize(), once the full body
+// (and therefore its final Content-Length) is known.
 func (rw *responseWriter) WriteHeader(statusCode int) {
+	if rw.headerWritten {
+		return
+	}
+	rw.headerWritten = true
+	rw.statusCode = statusCode
+
 	// Process Set-Cookie headers in the response
 	rw.transformResponseCookies()
+	if rw.config.SignedMode {
+		rw.addSignedCookie()
+	}
+	if rw.jar != nil && rw.sessionID != "" {
+		rw.captureJarCookies()
+	}
+
+	if rw.config.RewriteBody && matchesContentType(rw.Header().Get("Content-Type"), rw.config.RewriteBodyContentTypes) {
+		rw.bodyBuf = &bytes.Buffer{}
+		return
+	}
+
 	rw.ResponseWriter.WriteHeader(statusCode)
 }
 
-// transformResponseCookies modifies Set-Cookie headers in the response
+// Write buffers the response body while RewriteBody is in effect for this
+// response, falling back to a direct passthrough of everything seen so far
+// once more than RewriteBodyMaxBytes have accumulated.
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	if !rw.headerWritten {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	if rw.bodyBuf == nil {
+		return rw.ResponseWriter.Write(p)
+	}
+
+	if int64(rw.bodyBuf.Len()+len(p)) > rw.config.RewriteBodyMaxBytes {
+		rw.ResponseWriter.WriteHeader(rw.statusCode)
+		if rw.bodyBuf.Len() > 0 {
+			if _, err := rw.ResponseWriter.Write(rw.bodyBuf.Bytes()); err != nil {
+				return 0, err
+			}
+		}
+		rw.bodyBuf = nil
+		return rw.ResponseWriter.Write(p)
+	}
+
+	return rw.bodyBuf.Write(p)
+}
+
+// finalize flushes a body buffered for rewriting through the rewrite
+// pipeline and on to the real ResponseWriter. It also covers handlers that
+// never call WriteHeader or Write at all.
+func (rw *responseWriter) finalize() {
+	if !rw.headerWritten {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.bodyBuf == nil {
+		return
+	}
+
+	body := rw.bodyBuf.Bytes()
+	rw.bodyBuf = nil
+
+	if rewritten, ok := rewriteBody(body, rw.Header(), rw.config, rw.bodyRewriteRegex); ok {
+		rw.Header().Set("Content-Length", strconv.Itoa(len(rewritten)))
+		body = rewritten
+	}
+
+	rw.ResponseWriter.WriteHeader(rw.statusCode)
+	if len(body) > 0 {
+		rw.ResponseWriter.Write(body)
+	}
+}
+
+// transformResponseCookies runs every Set-Cookie header through the rule
+// pipeline. Each header is parsed into a real *http.Cookie via
+// parseSetCookie rather than matched with strings.Contains, so the
+// source cookie can't be confused with some other cookie whose value
+// happens to contain "name=". Cookies no rule matches are re-emitted
+// verbatim from the original header rather than reserialized, since
+// parseSetCookie/http.Cookie only understand a fixed attribute set and
+// would silently drop anything else (e.g. Partitioned, Priority) from a
+// cookie the plugin was never asked to touch.
 func (rw *responseWriter) transformResponseCookies() {
 	setCookieHeaders := rw.Header().Values("Set-Cookie")
 	if len(setCookieHeaders) == 0 {
 		return
 	}
 
+	if rw.metrics != nil {
+		defer func(start time.Time) { rw.metrics.ObserveLatency(time.Since(start)) }(time.Now())
+	}
+
 	if rw.config.Debug {
 		fmt.Printf("[Cookie Modifier] Processing %d Set-Cookie headers\n", len(setCookieHeaders))
 	}
 
-	var newSetCookieHeaders []string
+	domain := dynamicDomain(rw.req, rw.config, rw.publicSuffixes)
 
-	for _, setCookieHeader := range setCookieHeaders {
-		// Check if this Set-Cookie header contains our source cookie
-		if strings.Contains(setCookieHeader, rw.config.SourceCookieName+"=") {
-			// Transform this cookie
-			transformedHeader := strings.Replace(setCookieHeader,
-				rw.config.SourceCookieName+"=",
-				rw.config.TargetCookieName+"=", 1)
-
-			// Add domain if dynamic domain is enabled and not already present
-			if rw.config.UseDynamicDomain && !strings.Contains(transformedHeader, "Domain=") {
-				transformedHeader += fmt.Sprintf("; Domain=%s", rw.req.Host)
-			}
-
-			// Add path if not already present
-			if rw.config.Path != "/" && !strings.Contains(transformedHeader, "Path=") {
-				transformedHeader += fmt.Sprintf("; Path=%s", rw.config.Path)
-			}
-
-			// Add security attributes
-			if rw.config.Secure && !strings.Contains(transformedHeader, "Secure") {
-				transformedHeader += "; Secure"
-			}
-			if rw.config.HttpOnly && !strings.Contains(transformedHeader, "HttpOnly") {
-				transformedHeader += "; HttpOnly"
-			}
-			if rw.config.SameSite != "" && !strings.Contains(transformedHeader, "SameSite=") {
-				transformedHeader += fmt.Sprintf("; SameSite=%s", rw.config.SameSite)
-			}
-
-			newSetCookieHeaders = append(newSetCookieHeaders, transformedHeader)
+	var observe matchObserver
+	if rw.metrics != nil {
+		observe = func(rule string) { rw.metrics.IncTransform("response", rule) }
+	}
 
+	var newSetCookieHeaders []string
+	for _, setCookieHeader := range setCookieHeaders {
+		cookie, err := parseSetCookie(setCookieHeader)
+		if err != nil {
 			if rw.config.Debug {
-				fmt.Printf("[Cookie Modifier] Transformed Set-Cookie: %s\n", transformedHeader)
+				fmt.Printf("[Cookie Modifier] Failed to parse Set-Cookie header %q: %v\n", setCookieHeader, err)
 			}
-		} else {
-			// Keep other cookies as-is
 			newSetCookieHeaders = append(newSetCookieHeaders, setCookieHeader)
+			continue
+		}
+
+		for _, result := range runPipeline(cookie, rw.rules, domain, observe) {
+			header := setCookieHeader
+			if result != cookie {
+				header = result.String()
+				if rw.config.Debug {
+					fmt.Printf("[Cookie Modifier] Transformed Set-Cookie: %s\n", header)
+				}
+			}
+			newSetCookieHeaders = append(newSetCookieHeaders, header)
 		}
 	}
 
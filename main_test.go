@@ -2,11 +2,16 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestCookieModifier_BasicTransformation(t *testing.T) {
@@ -154,6 +159,546 @@ func TestCookieModifier_NoCookieTransformation(t *testing.T) {
 	}
 }
 
+func TestCookieModifier_RulesPipeline(t *testing.T) {
+	config := CreateConfig()
+	config.Rules = []RuleConfig{
+		{Name: "legacy_session", Drop: true},
+		{
+			NameRegex: `^session_(.+)$`,
+			Split: []SplitConfig{
+				{Name: "session_id", ValueTemplate: "$1"},
+				{Name: "session_marker", ValueTemplate: "seen"},
+			},
+			Stop: true,
+		},
+	}
+
+	gotCookies := make(map[string]string)
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		for _, c := range req.Cookies() {
+			gotCookies[c.Name] = c.Value
+		}
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	plugin, err := New(context.Background(), next, config, "test-cookie-modifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.AddCookie(&http.Cookie{Name: "legacy_session", Value: "drop-me"})
+	req.AddCookie(&http.Cookie{Name: "session_42", Value: "ignored"})
+	req.Host = "example.com"
+
+	rw := httptest.NewRecorder()
+	plugin.ServeHTTP(rw, req)
+
+	if _, ok := gotCookies["legacy_session"]; ok {
+		t.Error("expected legacy_session to be dropped")
+	}
+	if got := gotCookies["session_id"]; got != "42" {
+		t.Errorf("expected session_id=42, got %q", got)
+	}
+	if got := gotCookies["session_marker"]; got != "seen" {
+		t.Errorf("expected session_marker=seen, got %q", got)
+	}
+}
+
+func TestParseSetCookie(t *testing.T) {
+	c, err := parseSetCookie(`session="abc123"; Domain=.example.com; Path=/app; Secure; HttpOnly; SameSite=Strict; Max-Age=120`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Name != "session" || c.Value != "abc123" {
+		t.Errorf("got name=%q value=%q", c.Name, c.Value)
+	}
+	if c.Domain != "example.com" {
+		t.Errorf("expected leading dot stripped from Domain, got %q", c.Domain)
+	}
+	if c.Path != "/app" || !c.Secure || !c.HttpOnly {
+		t.Errorf("got Path=%q Secure=%v HttpOnly=%v", c.Path, c.Secure, c.HttpOnly)
+	}
+	if c.SameSite != http.SameSiteStrictMode {
+		t.Errorf("got SameSite=%v", c.SameSite)
+	}
+	if c.MaxAge != 120 {
+		t.Errorf("got MaxAge=%d", c.MaxAge)
+	}
+
+	if _, err := parseSetCookie("not-a-cookie"); err == nil {
+		t.Error("expected an error for a header with no name=value pair")
+	}
+}
+
+func TestCookieModifier_ResponsePassesThroughUnparsableSetCookie(t *testing.T) {
+	config := CreateConfig()
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Add("Set-Cookie", "???not a cookie???")
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	plugin, err := New(context.Background(), next, config, "test-cookie-modifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Host = "example.com"
+	rw := httptest.NewRecorder()
+	plugin.ServeHTTP(rw, req)
+
+	headers := rw.Header().Values("Set-Cookie")
+	if len(headers) != 1 || headers[0] != "???not a cookie???" {
+		t.Errorf("expected the unparsable header to pass through unchanged, got %v", headers)
+	}
+}
+
+func TestCookieModifier_ResponsePreservesAttributesOnUnmatchedCookie(t *testing.T) {
+	config := CreateConfig()
+	config.SourceCookieName = "flowise_token"
+	config.TargetCookieName = "simple_token"
+
+	original := `sid=xyz; Path=/; Secure; HttpOnly; SameSite=None; Partitioned; Priority=High`
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Add("Set-Cookie", original)
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	plugin, err := New(context.Background(), next, config, "test-cookie-modifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Host = "example.com"
+	rw := httptest.NewRecorder()
+	plugin.ServeHTTP(rw, req)
+
+	headers := rw.Header().Values("Set-Cookie")
+	if len(headers) != 1 || headers[0] != original {
+		t.Errorf("expected the unmatched cookie to pass through verbatim, got %v, want [%q]", headers, original)
+	}
+}
+
+func TestCookieModifier_SignedModeEmitsVerifiableSignature(t *testing.T) {
+	config := CreateConfig()
+	config.SignedMode = true
+	config.SigningSecret = "s3cr3t"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		http.SetCookie(rw, &http.Cookie{Name: "simple_token", Value: "abc", Path: "/"})
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	plugin, err := New(context.Background(), next, config, "test-cookie-modifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	rw := httptest.NewRecorder()
+	plugin.ServeHTTP(rw, req)
+
+	var sigValue string
+	for _, header := range rw.Header().Values("Set-Cookie") {
+		if strings.Contains(header, "simple_token.sig=") {
+			c, err := parseSetCookie(header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sigValue = c.Value
+		}
+	}
+	if sigValue == "" {
+		t.Fatal("expected a simple_token.sig companion cookie")
+	}
+	if want := signValue("s3cr3t", "abc"); sigValue != want {
+		t.Errorf("got signature %q, want %q", sigValue, want)
+	}
+}
+
+func TestCookieModifier_SignedModeStripsTamperedCookiePair(t *testing.T) {
+	config := CreateConfig()
+	config.SignedMode = true
+	config.SigningSecret = "s3cr3t"
+
+	var gotNames []string
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		for _, c := range req.Cookies() {
+			gotNames = append(gotNames, c.Name)
+		}
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	plugin, err := New(context.Background(), next, config, "test-cookie-modifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.AddCookie(&http.Cookie{Name: "simple_token", Value: "abc"})
+	req.AddCookie(&http.Cookie{Name: "simple_token.sig", Value: "not-the-real-signature"})
+
+	rw := httptest.NewRecorder()
+	plugin.ServeHTTP(rw, req)
+
+	for _, name := range gotNames {
+		if name == "simple_token" || name == "simple_token.sig" {
+			t.Errorf("expected tampered cookie pair to be stripped, upstream saw %q", name)
+		}
+	}
+}
+
+func TestCookieModifier_SignedModeRequiresTargetCookieNameWithRules(t *testing.T) {
+	config := &Config{
+		SignedMode:    true,
+		SigningSecret: "s3cr3t",
+		Rules:         []RuleConfig{{Name: "a", Rename: "b"}},
+	}
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	if _, err := New(context.Background(), next, config, "test-cookie-modifier"); err == nil {
+		t.Error("expected an error for signedMode+rules without an explicit targetCookieName")
+	}
+}
+
+func TestRegistrableDomain(t *testing.T) {
+	cases := map[string]string{
+		"app.example.com":   "example.com",
+		"app.example.co.uk": "example.co.uk",
+		"example.com":       "example.com",
+	}
+	for host, want := range cases {
+		if got := registrableDomain(host, nil); got != want {
+			t.Errorf("registrableDomain(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestRegistrableDomain_PublicSuffixesExtendsBakedInTable(t *testing.T) {
+	if got := registrableDomain("foo.github.io", nil); got != "github.io" {
+		t.Errorf("registrableDomain(%q) = %q, want %q (github.io not baked in, so it's treated as the eTLD+1)", "foo.github.io", got, "github.io")
+	}
+
+	extra := map[string]bool{"github.io": true}
+	if got := registrableDomain("foo.github.io", extra); got != "foo.github.io" {
+		t.Errorf("registrableDomain(%q) with extra = %q, want %q", "foo.github.io", got, "foo.github.io")
+	}
+}
+
+func TestParentDomain(t *testing.T) {
+	if got := parentDomain("app.example.com"); got != "example.com" {
+		t.Errorf("parentDomain(%q) = %q, want %q", "app.example.com", got, "example.com")
+	}
+	if got := parentDomain("example.com"); got != "com" {
+		t.Errorf("parentDomain(%q) = %q, want %q", "example.com", got, "com")
+	}
+}
+
+func TestIsPublicSuffix(t *testing.T) {
+	if !isPublicSuffix("com", nil) {
+		t.Error("expected a single-label domain to be treated as a public suffix")
+	}
+	if !isPublicSuffix("co.uk", nil) {
+		t.Error("expected co.uk to be recognized via twoLabelPublicSuffixes")
+	}
+	if isPublicSuffix("github.io", nil) {
+		t.Error("github.io is not baked in, so it should not be treated as a public suffix without extra")
+	}
+	if !isPublicSuffix("github.io", map[string]bool{"github.io": true}) {
+		t.Error("expected github.io to be recognized once passed as an extra suffix")
+	}
+	if isPublicSuffix("example.com", nil) {
+		t.Error("example.com is a real registrable domain, not a public suffix")
+	}
+}
+
+func TestCookieModifier_DomainStrategyParentOmitsDomainOnApexHost(t *testing.T) {
+	config := CreateConfig()
+	config.DomainStrategy = "parent"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		http.SetCookie(rw, &http.Cookie{Name: "flowise_token", Value: "v", Path: "/"})
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	plugin, err := New(context.Background(), next, config, "test-cookie-modifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Host = "example.com"
+	rw := httptest.NewRecorder()
+	plugin.ServeHTTP(rw, req)
+
+	found := false
+	for _, header := range rw.Header().Values("Set-Cookie") {
+		if strings.Contains(header, "simple_token=v") {
+			found = true
+			if strings.Contains(header, "Domain=") {
+				t.Errorf("expected no Domain attribute for an apex host under DomainStrategy \"parent\", got %q", header)
+			}
+		}
+	}
+	if !found {
+		t.Error("transformed cookie not found in Set-Cookie headers")
+	}
+}
+
+func TestCookieModifier_DomainStrategyRegistrable(t *testing.T) {
+	config := CreateConfig()
+	config.DomainStrategy = "registrable"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		http.SetCookie(rw, &http.Cookie{Name: "flowise_token", Value: "v", Path: "/"})
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	plugin, err := New(context.Background(), next, config, "test-cookie-modifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Host = "app.example.co.uk"
+	rw := httptest.NewRecorder()
+	plugin.ServeHTTP(rw, req)
+
+	found := false
+	for _, header := range rw.Header().Values("Set-Cookie") {
+		if strings.Contains(header, "simple_token=v") {
+			found = true
+			if !strings.Contains(header, "Domain=example.co.uk") {
+				t.Errorf("expected Domain=example.co.uk, got %q", header)
+			}
+		}
+	}
+	if !found {
+		t.Error("transformed cookie not found in Set-Cookie headers")
+	}
+}
+
+func TestCookieModifier_DomainStrategyRegistrable_PublicSuffixes(t *testing.T) {
+	config := CreateConfig()
+	config.DomainStrategy = "registrable"
+	config.PublicSuffixes = []string{"github.io"}
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		http.SetCookie(rw, &http.Cookie{Name: "flowise_token", Value: "v", Path: "/"})
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	plugin, err := New(context.Background(), next, config, "test-cookie-modifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Host = "foo.github.io"
+	rw := httptest.NewRecorder()
+	plugin.ServeHTTP(rw, req)
+
+	found := false
+	for _, header := range rw.Header().Values("Set-Cookie") {
+		if strings.Contains(header, "simple_token=v") {
+			found = true
+			if !strings.Contains(header, "Domain=foo.github.io") {
+				t.Errorf("expected Domain=foo.github.io, got %q", header)
+			}
+		}
+	}
+	if !found {
+		t.Error("transformed cookie not found in Set-Cookie headers")
+	}
+}
+
+func TestSessionJar_SetAndGetCookies(t *testing.T) {
+	jar := NewSessionJar(10)
+	u := &url.URL{Host: "session-1"}
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "a", Value: "1", Path: "/"}})
+
+	got := jar.Cookies(u)
+	if len(got) != 1 || got[0].Value != "1" {
+		t.Errorf("unexpected cookies: %+v", got)
+	}
+}
+
+func TestSessionJar_RemovalMarkerDropsCookie(t *testing.T) {
+	jar := NewSessionJar(10)
+	u := &url.URL{Host: "session-1"}
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "a", Value: "1", Path: "/"}})
+	jar.SetCookies(u, []*http.Cookie{{Name: "a", Value: "", Path: "/", MaxAge: -1}})
+
+	if got := jar.Cookies(u); len(got) != 0 {
+		t.Errorf("expected no cookies after a MaxAge<0 removal marker, got %+v", got)
+	}
+}
+
+func TestSessionJar_EvictsLeastRecentlyUsedSession(t *testing.T) {
+	jar := NewSessionJar(1)
+
+	jar.SetCookies(&url.URL{Host: "s1"}, []*http.Cookie{{Name: "a", Value: "1", Path: "/"}})
+	jar.SetCookies(&url.URL{Host: "s2"}, []*http.Cookie{{Name: "a", Value: "2", Path: "/"}})
+
+	if got := jar.Cookies(&url.URL{Host: "s1"}); len(got) != 0 {
+		t.Errorf("expected session s1 to be evicted once the jar exceeded 1 entry, got %+v", got)
+	}
+	if got := jar.Cookies(&url.URL{Host: "s2"}); len(got) != 1 {
+		t.Errorf("expected session s2 to survive, got %+v", got)
+	}
+}
+
+func TestRewriteBody_LiteralReplacement(t *testing.T) {
+	config := CreateConfig()
+	config.RewriteBody = true
+	config.RewriteBodyContentTypes = []string{"application/json"}
+
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	body := []byte(`{"name":"flowise_token"}`)
+
+	rewritten, ok := rewriteBody(body, header, config, nil)
+	if !ok {
+		t.Fatal("expected the rewrite to apply")
+	}
+	if !strings.Contains(string(rewritten), "simple_token") {
+		t.Errorf("expected rewritten body to contain the target name, got %s", rewritten)
+	}
+}
+
+func TestRewriteBody_GzipRoundTrip(t *testing.T) {
+	config := CreateConfig()
+	config.RewriteBody = true
+	config.RewriteBodyContentTypes = []string{"text/*"}
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write([]byte("token=flowise_token")); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	header := http.Header{
+		"Content-Type":     []string{"text/plain"},
+		"Content-Encoding": []string{"gzip"},
+	}
+
+	rewritten, ok := rewriteBody(buf.Bytes(), header, config, nil)
+	if !ok {
+		t.Fatal("expected the rewrite to apply")
+	}
+
+	gr, err := gzip.NewReader(bytes.NewReader(rewritten))
+	if err != nil {
+		t.Fatalf("rewritten body is not valid gzip: %v", err)
+	}
+	plain, err := io.ReadAll(gr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(plain), "simple_token") {
+		t.Errorf("expected decoded rewritten body to contain the target name, got %s", plain)
+	}
+}
+
+func TestRewriteBody_UnsupportedEncodingPassesThrough(t *testing.T) {
+	config := CreateConfig()
+	config.RewriteBody = true
+	config.RewriteBodyContentTypes = []string{"text/*"}
+
+	header := http.Header{
+		"Content-Type":     []string{"text/plain"},
+		"Content-Encoding": []string{"br"},
+	}
+	body := []byte("some brotli-compressed bytes, not actually compressed here")
+
+	rewritten, ok := rewriteBody(body, header, config, nil)
+	if ok || !bytes.Equal(rewritten, body) {
+		t.Errorf("expected a br-encoded body to pass through unchanged, got ok=%v body=%s", ok, rewritten)
+	}
+}
+
+func TestCookieModifier_RewriteBodyInvalidPatternRejectedAtNew(t *testing.T) {
+	config := CreateConfig()
+	config.RewriteBody = true
+	config.RewriteBodyContentTypes = []string{"application/json"}
+	config.RewriteBodyStrategy = "regex"
+	config.RewriteBodyPattern = "(unclosed"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	if _, err := New(context.Background(), next, config, "test-cookie-modifier"); err == nil {
+		t.Error("expected an error for an invalid rewriteBodyPattern")
+	}
+}
+
+func TestCookieModifier_RewriteBodyRegexStrategy(t *testing.T) {
+	config := CreateConfig()
+	config.RewriteBody = true
+	config.RewriteBodyContentTypes = []string{"application/json"}
+	config.RewriteBodyStrategy = "regex"
+	config.RewriteBodyPattern = `"tokenCookie":\s*"flowise_token"`
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"tokenCookie": "flowise_token"}`))
+	})
+
+	plugin, err := New(context.Background(), next, config, "test-cookie-modifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	rw := httptest.NewRecorder()
+	plugin.ServeHTTP(rw, req)
+
+	if body := rw.Body.String(); !strings.Contains(body, "simple_token") {
+		t.Errorf("expected regex-rewritten body to contain the target name, got %s", body)
+	}
+}
+
+func TestMetrics_RendersPrometheusFormat(t *testing.T) {
+	m := NewMetrics()
+	m.IncRequests()
+	m.IncTransform("request", "rule#0")
+	m.ObserveLatency(10 * time.Millisecond)
+
+	rw := httptest.NewRecorder()
+	m.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := rw.Body.String()
+	if !strings.Contains(body, "cookie_modifier_requests_total 1") {
+		t.Errorf("expected requests_total=1 in output, got:\n%s", body)
+	}
+	if !strings.Contains(body, `cookie_modifier_transforms_total{direction="request",rule="rule#0"} 1`) {
+		t.Errorf("expected a transform count in output, got:\n%s", body)
+	}
+}
+
+func TestAcquireMetrics_ReusesServerForSameAddr(t *testing.T) {
+	addr := "127.0.0.1:0"
+
+	first, err := acquireMetrics(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := acquireMetrics(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second {
+		t.Error("expected acquireMetrics to reuse the existing Metrics for an address it already serves")
+	}
+}
+
 func TestCookieModifier_InvalidConfig(t *testing.T) {
 	config := &Config{
 		SourceCookieName: "", // Invalid - empty
@@ -0,0 +1,172 @@
+// metrics.go
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// metricsNamespace prefixes every metric name, as is conventional for
+// Prometheus exporters.
+const metricsNamespace = "cookie_modifier"
+
+// latencyBuckets are the histogram bucket bounds, in seconds, for the
+// transform-latency histogram.
+var latencyBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+// transformLabel is the {direction,rule} label pair on
+// cookie_modifier_transforms_total.
+type transformLabel struct {
+	direction string
+	rule      string
+}
+
+// Metrics is a small, dependency-free counter/histogram registry.
+// prometheus/client_golang isn't an option here: Traefik plugins run
+// inside a Yaegi interpreter restricted to the standard library, and this
+// repo has no go.mod to vendor a dependency through even for the parts of
+// client_golang that do interpret cleanly. So metrics are tracked with
+// plain counters behind a mutex and rendered in the Prometheus text
+// exposition format by hand.
+type Metrics struct {
+	mu                sync.Mutex
+	requestsTotal     uint64
+	transformsTotal   map[transformLabel]uint64
+	signatureFailures uint64
+	latencyBuckets    []uint64
+	latencyCount      uint64
+	latencySum        float64
+}
+
+// NewMetrics creates an empty metrics registry.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		transformsTotal: make(map[transformLabel]uint64),
+		latencyBuckets:  make([]uint64, len(latencyBuckets)),
+	}
+}
+
+// IncRequests counts one more request seen by the plugin.
+func (m *Metrics) IncRequests() {
+	m.mu.Lock()
+	m.requestsTotal++
+	m.mu.Unlock()
+}
+
+// IncTransform counts one cookie transformation applied by rule, on either
+// the "request" or "response" direction.
+func (m *Metrics) IncTransform(direction, rule string) {
+	m.mu.Lock()
+	m.transformsTotal[transformLabel{direction, rule}]++
+	m.mu.Unlock()
+}
+
+// IncSignatureFailure counts one failed signed-cookie verification.
+func (m *Metrics) IncSignatureFailure() {
+	m.mu.Lock()
+	m.signatureFailures++
+	m.mu.Unlock()
+}
+
+// ObserveLatency records one transform-pipeline duration. It increments only
+// the first bucket the observation falls into; ServeHTTP derives every
+// bucket's cumulative count from that at render time, per the Prometheus
+// histogram convention (each le="..." bucket count includes all lower
+// buckets, so incrementing more than one bucket here would double-count).
+func (m *Metrics) ObserveLatency(d time.Duration) {
+	seconds := d.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.latencyCount++
+	m.latencySum += seconds
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			m.latencyBuckets[i]++
+			break
+		}
+	}
+}
+
+// ServeHTTP renders the registry in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s_requests_total Total requests seen by the plugin.\n", metricsNamespace)
+	fmt.Fprintf(w, "# TYPE %s_requests_total counter\n", metricsNamespace)
+	fmt.Fprintf(w, "%s_requests_total %d\n", metricsNamespace, m.requestsTotal)
+
+	fmt.Fprintf(w, "# HELP %s_transforms_total Cookie transformations applied, by direction and rule.\n", metricsNamespace)
+	fmt.Fprintf(w, "# TYPE %s_transforms_total counter\n", metricsNamespace)
+	for label, count := range m.transformsTotal {
+		fmt.Fprintf(w, "%s_transforms_total{direction=%q,rule=%q} %d\n", metricsNamespace, label.direction, label.rule, count)
+	}
+
+	fmt.Fprintf(w, "# HELP %s_signature_failures_total Signed cookie verifications that failed.\n", metricsNamespace)
+	fmt.Fprintf(w, "# TYPE %s_signature_failures_total counter\n", metricsNamespace)
+	fmt.Fprintf(w, "%s_signature_failures_total %d\n", metricsNamespace, m.signatureFailures)
+
+	fmt.Fprintf(w, "# HELP %s_transform_latency_seconds Time spent running the transform pipeline.\n", metricsNamespace)
+	fmt.Fprintf(w, "# TYPE %s_transform_latency_seconds histogram\n", metricsNamespace)
+	var cumulative uint64
+	for i, bound := range latencyBuckets {
+		cumulative += m.latencyBuckets[i]
+		fmt.Fprintf(w, "%s_transform_latency_seconds_bucket{le=%q} %d\n", metricsNamespace, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(w, "%s_transform_latency_seconds_bucket{le=\"+Inf\"} %d\n", metricsNamespace, m.latencyCount)
+	fmt.Fprintf(w, "%s_transform_latency_seconds_sum %g\n", metricsNamespace, m.latencySum)
+	fmt.Fprintf(w, "%s_transform_latency_seconds_count %d\n", metricsNamespace, m.latencyCount)
+}
+
+// metricsServers tracks the one metrics HTTP server started per address, so
+// that Traefik's routine practice of calling New() again on every dynamic
+// configuration reload reuses the existing listener and its Metrics instead
+// of trying (and, since the old listener is still bound, failing) to start a
+// second one.
+var (
+	metricsServersMu sync.Mutex
+	metricsServers   = make(map[string]*Metrics)
+)
+
+// acquireMetrics returns the Metrics registry backing the server on addr,
+// starting that server on first use. A bind failure is returned to the
+// caller rather than only logged, since New() has no other way to surface
+// that /metrics is about to go dark.
+func acquireMetrics(addr string) (*Metrics, error) {
+	metricsServersMu.Lock()
+	defer metricsServersMu.Unlock()
+
+	if m, ok := metricsServers[addr]; ok {
+		return m, nil
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: failed to bind %s: %w", addr, err)
+	}
+
+	m := NewMetrics()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	server := &http.Server{Handler: mux}
+
+	metricsServers[addr] = m
+
+	// Traefik plugins have no shutdown hook to stop this cleanly; it runs
+	// for the lifetime of the process.
+	go func() {
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("[Cookie Modifier] metrics server on %s stopped: %v\n", addr, err)
+		}
+	}()
+
+	return m, nil
+}
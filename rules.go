@@ -0,0 +1,292 @@
+// rules.go
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// SplitConfig describes one of the cookies produced when a RuleConfig splits
+// a single incoming cookie into several outgoing ones.
+type SplitConfig struct {
+	Name          string `json:"name,omitempty"`
+	ValueTemplate string `json:"valueTemplate,omitempty"`
+}
+
+// RuleConfig describes a single step of the cookie transformation pipeline.
+// Rules are evaluated in declaration order against every cookie; the first
+// rule that matches a given cookie applies, unless Stop is false and a later
+// rule also matches the result.
+//
+// A rule matches a cookie when at least one of Name, NameRegex or ValueRegex
+// is set and satisfied. A rule with none of the three set matches every
+// cookie, which is useful as a catch-all default at the end of the list.
+type RuleConfig struct {
+	// Matchers.
+	Name       string `json:"name,omitempty"`
+	NameRegex  string `json:"nameRegex,omitempty"`
+	ValueRegex string `json:"valueRegex,omitempty"`
+
+	// Actions.
+	Rename        string `json:"rename,omitempty"`
+	ValueTemplate string `json:"valueTemplate,omitempty"`
+	Path          string `json:"path,omitempty"`
+	Domain        string `json:"domain,omitempty"`
+	Secure        *bool  `json:"secure,omitempty"`
+	HttpOnly      *bool  `json:"httpOnly,omitempty"`
+	SameSite      string `json:"sameSite,omitempty"`
+
+	RemoveAttributes []string      `json:"removeAttributes,omitempty"`
+	Split            []SplitConfig `json:"split,omitempty"`
+	Drop             bool          `json:"drop,omitempty"`
+
+	// Stop, when true, prevents subsequent rules from being evaluated
+	// against the cookie once this rule has matched.
+	Stop bool `json:"stop,omitempty"`
+}
+
+// compiledRule is a RuleConfig with its regexes compiled once at New(), so
+// ServeHTTP never pays regexp.Compile cost on the request path.
+type compiledRule struct {
+	cfg        RuleConfig
+	nameRegex  *regexp.Regexp
+	valueRegex *regexp.Regexp
+	// label identifies the rule for metrics, since RuleConfig has no
+	// dedicated name field of its own.
+	label string
+}
+
+// compileRules validates and compiles config.Rules. When config.Rules is
+// empty it synthesizes a single rule from the legacy top-level fields so
+// existing configurations keep working unchanged.
+func compileRules(config *Config) ([]compiledRule, error) {
+	rules := config.Rules
+	if len(rules) == 0 {
+		rules = []RuleConfig{legacyRule(config)}
+	}
+
+	compiled := make([]compiledRule, 0, len(rules))
+	for i, cfg := range rules {
+		cr := compiledRule{cfg: cfg, label: ruleLabel(cfg, i)}
+
+		if cfg.NameRegex != "" {
+			re, err := regexp.Compile(cfg.NameRegex)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: invalid nameRegex: %w", i, err)
+			}
+			cr.nameRegex = re
+		}
+
+		if cfg.ValueRegex != "" {
+			re, err := regexp.Compile(cfg.ValueRegex)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: invalid valueRegex: %w", i, err)
+			}
+			cr.valueRegex = re
+		}
+
+		compiled = append(compiled, cr)
+	}
+
+	return compiled, nil
+}
+
+// ruleLabel identifies a rule for metrics purposes, since RuleConfig has no
+// dedicated name field.
+func ruleLabel(cfg RuleConfig, index int) string {
+	switch {
+	case cfg.Name != "":
+		return cfg.Name
+	case cfg.NameRegex != "":
+		return "nameRegex:" + cfg.NameRegex
+	case cfg.ValueRegex != "":
+		return "valueRegex:" + cfg.ValueRegex
+	default:
+		return fmt.Sprintf("rule#%d", index)
+	}
+}
+
+// legacyRule translates the original single source->target fields into the
+// equivalent RuleConfig, preserving pre-multi-rule behavior.
+func legacyRule(config *Config) RuleConfig {
+	return RuleConfig{
+		Name:     config.SourceCookieName,
+		Rename:   config.TargetCookieName,
+		Path:     config.Path,
+		Secure:   &config.Secure,
+		HttpOnly: &config.HttpOnly,
+		SameSite: config.SameSite,
+		Domain:   legacyDomainPlaceholder,
+		Stop:     true,
+	}
+}
+
+// legacyDomainPlaceholder marks a rule's Domain as "derive it the legacy,
+// UseDynamicDomain-driven way" rather than a literal domain string, since
+// RuleConfig has no way to express "dynamic" otherwise.
+const legacyDomainPlaceholder = "\x00dynamic"
+
+// matches reports whether cr matches cookie. See RuleConfig's doc comment
+// for the matching semantics.
+func (cr *compiledRule) matches(cookie *http.Cookie) bool {
+	if cr.cfg.Name == "" && cr.nameRegex == nil && cr.valueRegex == nil {
+		return true
+	}
+	if cr.cfg.Name != "" && cookie.Name == cr.cfg.Name {
+		return true
+	}
+	if cr.nameRegex != nil && cr.nameRegex.MatchString(cookie.Name) {
+		return true
+	}
+	if cr.valueRegex != nil && cr.valueRegex.MatchString(cookie.Value) {
+		return true
+	}
+	return false
+}
+
+// apply runs cr's actions against cookie, returning the cookies that should
+// replace it (zero cookies if the rule drops it, more than one if it
+// splits it). dynamicDomain is the value to use wherever a rule asks for
+// the legacy dynamic-domain behavior.
+func (cr *compiledRule) apply(cookie *http.Cookie, dynamicDomain string) []*http.Cookie {
+	if cr.cfg.Drop {
+		return nil
+	}
+
+	if len(cr.cfg.Split) > 0 {
+		out := make([]*http.Cookie, 0, len(cr.cfg.Split))
+		for _, split := range cr.cfg.Split {
+			value := cookie.Value
+			if split.ValueTemplate != "" {
+				value = cr.rewriteValue(cookie, split.ValueTemplate)
+			}
+			child := cr.decorate(&http.Cookie{Name: split.Name, Value: value}, dynamicDomain)
+			out = append(out, child)
+		}
+		return out
+	}
+
+	name := cookie.Name
+	if cr.cfg.Rename != "" {
+		name = cr.cfg.Rename
+	}
+
+	value := cookie.Value
+	if cr.cfg.ValueTemplate != "" {
+		value = cr.rewriteValue(cookie, cr.cfg.ValueTemplate)
+	}
+
+	transformed := cr.decorate(&http.Cookie{Name: name, Value: value}, dynamicDomain)
+	return []*http.Cookie{transformed}
+}
+
+// rewriteValue expands capture-group references (e.g. "$1") from whichever
+// regex matched the cookie against template.
+func (cr *compiledRule) rewriteValue(cookie *http.Cookie, template string) string {
+	switch {
+	case cr.valueRegex != nil && cr.valueRegex.MatchString(cookie.Value):
+		return cr.valueRegex.ReplaceAllString(cookie.Value, template)
+	case cr.nameRegex != nil && cr.nameRegex.MatchString(cookie.Name):
+		return cr.nameRegex.ReplaceAllString(cookie.Name, template)
+	default:
+		return template
+	}
+}
+
+// decorate applies the rule's attribute actions to an already name/value
+// resolved cookie.
+func (cr *compiledRule) decorate(cookie *http.Cookie, dynamicDomain string) *http.Cookie {
+	cfg := cr.cfg
+
+	if cfg.Path != "" {
+		cookie.Path = cfg.Path
+	}
+	if cfg.Domain != "" {
+		cookie.Domain = cfg.Domain
+		if cookie.Domain == legacyDomainPlaceholder {
+			cookie.Domain = dynamicDomain
+		}
+	}
+	if cfg.Secure != nil {
+		cookie.Secure = *cfg.Secure
+	}
+	if cfg.HttpOnly != nil {
+		cookie.HttpOnly = *cfg.HttpOnly
+	}
+	if cfg.SameSite != "" {
+		cookie.SameSite = sameSiteFromString(cfg.SameSite)
+	}
+
+	for _, attr := range cfg.RemoveAttributes {
+		switch attr {
+		case "Domain":
+			cookie.Domain = ""
+		case "Path":
+			cookie.Path = ""
+		case "Secure":
+			cookie.Secure = false
+		case "HttpOnly":
+			cookie.HttpOnly = false
+		case "SameSite":
+			cookie.SameSite = http.SameSiteDefaultMode
+		}
+	}
+
+	return cookie
+}
+
+// sameSiteFromString maps the plugin's string config values to http.SameSite.
+func sameSiteFromString(value string) http.SameSite {
+	switch value {
+	case "Strict":
+		return http.SameSiteStrictMode
+	case "Lax":
+		return http.SameSiteLaxMode
+	case "None":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteDefaultMode
+	}
+}
+
+// matchObserver is notified, by rule label, each time a rule matches a
+// cookie during runPipeline. Used to feed transform counts to Metrics.
+type matchObserver func(ruleLabel string)
+
+// runPipeline evaluates rules, in order, against cookie, threading the
+// result of each match into the next rule so splits and renames can be
+// matched again downstream. Evaluation for a given cookie stops as soon as
+// a matching rule has Stop set. Returns the resulting set of cookies, which
+// may be empty (dropped) or contain more than one (split). observe may be
+// nil.
+func runPipeline(cookie *http.Cookie, rules []compiledRule, dynamicDomain string, observe matchObserver) []*http.Cookie {
+	pending := []*http.Cookie{cookie}
+
+	for i := range rules {
+		rule := &rules[i]
+		var next []*http.Cookie
+		matched := false
+
+		for _, c := range pending {
+			if !rule.matches(c) {
+				next = append(next, c)
+				continue
+			}
+			matched = true
+			next = append(next, rule.apply(c, dynamicDomain)...)
+		}
+
+		pending = next
+		if matched {
+			if observe != nil {
+				observe(rule.label)
+			}
+			if rule.cfg.Stop {
+				break
+			}
+		}
+	}
+
+	return pending
+}
@@ -0,0 +1,112 @@
+// signing.go
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// sigCookieSuffix names the paired verification cookie emitted alongside
+// the target cookie when SignedMode is enabled, e.g. "simple_token.sig".
+const sigCookieSuffix = ".sig"
+
+// signValue returns the base64-encoded HMAC-SHA256 of value under secret.
+func signValue(secret, value string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(value))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature reports whether sig is the correct signature for value
+// under secret. The comparison is constant-time.
+func verifySignature(secret, value, sig string) bool {
+	return hmac.Equal([]byte(signValue(secret, value)), []byte(sig))
+}
+
+// verifyRequestSignature checks the target cookie in req against its paired
+// ".sig" cookie. It returns true when there's nothing to verify (the target
+// cookie isn't present) or the signature checks out. On failure it strips
+// both the target and the signature cookie from the request so the
+// upstream never sees an unverified value.
+//
+// Signing is scoped to cm.config.TargetCookieName, not the rule pipeline
+// from Rules: New() requires TargetCookieName to be set whenever SignedMode
+// is enabled, Rules or no, precisely so this stays meaningful.
+func (cm *CookieModifier) verifyRequestSignature(req *http.Request) bool {
+	var target, sig *http.Cookie
+	for _, c := range req.Cookies() {
+		switch c.Name {
+		case cm.config.TargetCookieName:
+			target = c
+		case cm.config.TargetCookieName + sigCookieSuffix:
+			sig = c
+		}
+	}
+
+	if target == nil {
+		return true
+	}
+
+	if sig != nil && verifySignature(cm.config.SigningSecret, target.Value, sig.Value) {
+		return true
+	}
+
+	if cm.config.Debug {
+		fmt.Printf("[Cookie Modifier] Signature check failed for %s\n", cm.config.TargetCookieName)
+	}
+	stripRequestCookies(req, cm.config.TargetCookieName, cm.config.TargetCookieName+sigCookieSuffix)
+	return false
+}
+
+// stripRequestCookies rebuilds the Cookie header with the named cookies
+// removed.
+func stripRequestCookies(req *http.Request, names ...string) {
+	drop := make(map[string]bool, len(names))
+	for _, name := range names {
+		drop[name] = true
+	}
+
+	var kept []string
+	for _, c := range req.Cookies() {
+		if drop[c.Name] {
+			continue
+		}
+		nameValue := &http.Cookie{Name: c.Name, Value: c.Value}
+		kept = append(kept, nameValue.String())
+	}
+	req.Header.Set("Cookie", strings.Join(kept, "; "))
+}
+
+// addSignedCookie emits a paired "<name>.sig" Set-Cookie for the target
+// cookie, mirroring its attributes, so the downstream client holds a
+// tamper-evident pair.
+func (rw *responseWriter) addSignedCookie() {
+	for _, header := range rw.Header().Values("Set-Cookie") {
+		cookie, err := parseSetCookie(header)
+		if err != nil || cookie.Name != rw.config.TargetCookieName {
+			continue
+		}
+
+		sig := &http.Cookie{
+			Name:     cookie.Name + sigCookieSuffix,
+			Value:    signValue(rw.config.SigningSecret, cookie.Value),
+			Path:     cookie.Path,
+			Domain:   cookie.Domain,
+			Expires:  cookie.Expires,
+			MaxAge:   cookie.MaxAge,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+			SameSite: cookie.SameSite,
+		}
+		rw.Header().Add("Set-Cookie", sig.String())
+
+		if rw.config.Debug {
+			fmt.Printf("[Cookie Modifier] Added signed companion cookie: %s\n", sig.Name)
+		}
+		return
+	}
+}